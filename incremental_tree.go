@@ -0,0 +1,431 @@
+// MIT License
+//
+// Copyright (c) 2023 Tommy TIAN
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package merkletree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// ErrIncrementalConsistencyRange is the error message for an invalid (oldSize, newSize) pair
+	// passed to a consistency proof request.
+	ErrIncrementalConsistencyRange = "old size must be greater than 0 and not exceed new size"
+	// ErrIncrementalIndexOutOfRange is the error message for a leaf index that has not been appended yet.
+	ErrIncrementalIndexOutOfRange = "leaf index is out of range"
+	// ErrIncrementalFrontierCorrupt is the error message for a frontier state that fails to deserialize.
+	ErrIncrementalFrontierCorrupt = "frontier state bytes are corrupt"
+)
+
+// frontierNode is a single perfect-subtree root kept on the incremental tree's frontier stack.
+// height is 0 for a leaf and increases by one every time two equal-height subtrees are merged.
+type frontierNode struct {
+	hash   []byte
+	height uint8
+}
+
+// IncrementalTree is an append-only Merkle Tree that ingests data blocks one at a time through
+// Append (there is no separate batch append: a batch is added with one Append call per block),
+// without knowing the total number of leaves in advance. Rather than keeping the full
+// node array, it maintains only the O(log N) "frontier" of perfect-subtree roots produced so far,
+// in the style of a Certificate-Transparency log or a Merkle Mountain Range.
+type IncrementalTree struct {
+	Config
+	// frontier holds the current perfect-subtree roots, ordered from the largest (earliest, and
+	// therefore leftmost) subtree to the smallest (most recently completed) one.
+	frontier []frontierNode
+	// size is the number of leaves appended so far.
+	size uint64
+}
+
+// FrontierState is a serializable snapshot of an IncrementalTree's frontier, suitable for durable
+// storage so that appending can resume across process restarts without replaying every leaf.
+type FrontierState struct {
+	// Size is the number of leaves that had been appended when the snapshot was taken.
+	Size uint64
+	// Frontier holds the perfect-subtree roots, ordered largest to smallest, as kept by IncrementalTree.
+	Frontier []FrontierEntry
+}
+
+// FrontierEntry is a single perfect-subtree root within a FrontierState.
+type FrontierEntry struct {
+	Hash   []byte
+	Height uint8
+}
+
+// NewIncremental generates a new, empty IncrementalTree with the specified configuration.
+func NewIncremental(config *Config) *IncrementalTree {
+	if config == nil {
+		config = new(Config)
+	}
+	t := &IncrementalTree{Config: *config}
+	if t.HashFunc == nil {
+		t.HashFunc = DefaultHashFunc
+	}
+	if t.concatFunc == nil {
+		if t.SortSiblingPairs {
+			t.concatFunc = concatSortHash
+		} else {
+			t.concatFunc = concatHash
+		}
+	}
+	return t
+}
+
+// Append hashes block and adds it as the next leaf of the tree, returning its (zero-based) index.
+// Internally, the new leaf is merged into the frontier stack: it is pushed as a height-0 node, and
+// then repeatedly combined with the top-of-stack node whenever two subtrees of equal height are
+// adjacent, collapsing the stack the same way a binary counter carries.
+func (t *IncrementalTree) Append(block DataBlock) (index uint64, err error) {
+	if block == nil {
+		return 0, errors.New(ErrDataBlockIsNil)
+	}
+	leaf, err := leafFromBlock(block, &t.Config)
+	if err != nil {
+		return 0, err
+	}
+	index = t.size
+	t.frontier = append(t.frontier, frontierNode{hash: leaf, height: 0})
+	for {
+		n := len(t.frontier)
+		if n < 2 || t.frontier[n-1].height != t.frontier[n-2].height {
+			break
+		}
+		height := t.frontier[n-2].height
+		var combined []byte
+		if combined, err = t.HashFunc(t.concatFunc(t.frontier[n-2].hash, t.frontier[n-1].hash)); err != nil {
+			return 0, err
+		}
+		t.frontier = t.frontier[:n-2]
+		t.frontier = append(t.frontier, frontierNode{hash: combined, height: height + 1})
+	}
+	t.size++
+	return index, nil
+}
+
+// Root folds the current frontier right-to-left into a single Merkle root. If the tree is empty,
+// Root returns nil.
+func (t *IncrementalTree) Root() ([]byte, error) {
+	if len(t.frontier) == 0 {
+		return nil, nil
+	}
+	acc := t.frontier[len(t.frontier)-1].hash
+	for i := len(t.frontier) - 2; i >= 0; i-- {
+		combined, err := t.HashFunc(t.concatFunc(t.frontier[i].hash, acc))
+		if err != nil {
+			return nil, err
+		}
+		acc = combined
+	}
+	return acc, nil
+}
+
+// Size returns the number of leaves appended to the tree so far.
+func (t *IncrementalTree) Size() uint64 {
+	return t.size
+}
+
+// Snapshot captures the current frontier so that it can be persisted and later restored with Restore.
+func (t *IncrementalTree) Snapshot() FrontierState {
+	entries := make([]FrontierEntry, len(t.frontier))
+	for i, node := range t.frontier {
+		hash := make([]byte, len(node.hash))
+		copy(hash, node.hash)
+		entries[i] = FrontierEntry{Hash: hash, Height: node.height}
+	}
+	return FrontierState{Size: t.size, Frontier: entries}
+}
+
+// Restore resets the tree's frontier to a previously captured FrontierState, allowing appends to
+// resume where a prior process left off.
+func (t *IncrementalTree) Restore(state FrontierState) {
+	t.size = state.Size
+	t.frontier = make([]frontierNode, len(state.Frontier))
+	for i, entry := range state.Frontier {
+		hash := make([]byte, len(entry.Hash))
+		copy(hash, entry.Hash)
+		t.frontier[i] = frontierNode{hash: hash, height: entry.Height}
+	}
+}
+
+// Serialize encodes the FrontierState to bytes: a big-endian Size, followed by a 4-byte entry
+// count, followed by each entry as a 1-byte height, a 4-byte hash length, and the hash itself.
+func (s FrontierState) Serialize() ([]byte, error) {
+	size := 8 + 4
+	for _, entry := range s.Frontier {
+		size += 1 + 4 + len(entry.Hash)
+	}
+	buf := make([]byte, size)
+	offset := 0
+	binary.BigEndian.PutUint64(buf[offset:], s.Size)
+	offset += 8
+	binary.BigEndian.PutUint32(buf[offset:], uint32(len(s.Frontier)))
+	offset += 4
+	for _, entry := range s.Frontier {
+		buf[offset] = entry.Height
+		offset++
+		binary.BigEndian.PutUint32(buf[offset:], uint32(len(entry.Hash)))
+		offset += 4
+		copy(buf[offset:], entry.Hash)
+		offset += len(entry.Hash)
+	}
+	return buf, nil
+}
+
+// DeserializeFrontierState decodes a FrontierState previously produced by FrontierState.Serialize.
+func DeserializeFrontierState(data []byte) (state FrontierState, err error) {
+	if len(data) < 12 {
+		return state, errors.New(ErrIncrementalFrontierCorrupt)
+	}
+	offset := 0
+	state.Size = binary.BigEndian.Uint64(data[offset:])
+	offset += 8
+	numEntries := int(binary.BigEndian.Uint32(data[offset:]))
+	offset += 4
+	state.Frontier = make([]FrontierEntry, numEntries)
+	for i := 0; i < numEntries; i++ {
+		if offset+5 > len(data) {
+			return FrontierState{}, errors.New(ErrIncrementalFrontierCorrupt)
+		}
+		height := data[offset]
+		offset++
+		hashLen := int(binary.BigEndian.Uint32(data[offset:]))
+		offset += 4
+		if offset+hashLen > len(data) {
+			return FrontierState{}, errors.New(ErrIncrementalFrontierCorrupt)
+		}
+		hash := make([]byte, hashLen)
+		copy(hash, data[offset:offset+hashLen])
+		offset += hashLen
+		state.Frontier[i] = FrontierEntry{Hash: hash, Height: height}
+	}
+	return state, nil
+}
+
+// IncrementalProver produces inclusion and consistency proofs for an IncrementalTree. It keeps
+// every observed leaf hash, and alongside them a sidecar log of the frontier snapshot observed
+// after every append; Prove and ConsistencyProof recompute their hashes from the stored leaf
+// hashes and do not read the snapshot log.
+type IncrementalProver struct {
+	Config
+	// log[i] is the frontier snapshot immediately after the leaf at index i was appended.
+	log []FrontierState
+	// leaves holds every leaf hash appended so far, in order.
+	leaves [][]byte
+}
+
+// NewIncrementalProver creates an IncrementalProver tracking the same configuration as the
+// IncrementalTree it will be paired with.
+func NewIncrementalProver(config *Config) *IncrementalProver {
+	if config == nil {
+		config = new(Config)
+	}
+	p := &IncrementalProver{Config: *config}
+	if p.HashFunc == nil {
+		p.HashFunc = DefaultHashFunc
+	}
+	if p.concatFunc == nil {
+		if p.SortSiblingPairs {
+			p.concatFunc = concatSortHash
+		} else {
+			p.concatFunc = concatHash
+		}
+	}
+	return p
+}
+
+// Observe records the frontier snapshot and leaf hash for the append that just occurred on the
+// paired IncrementalTree, and must be called once per Append, in order.
+func (p *IncrementalProver) Observe(leaf []byte, snapshot FrontierState) {
+	leafCopy := make([]byte, len(leaf))
+	copy(leafCopy, leaf)
+	p.leaves = append(p.leaves, leafCopy)
+	p.log = append(p.log, snapshot)
+}
+
+// Prove produces an inclusion proof for the leaf appended at index, as of the most recent Observe
+// call (i.e. against the current size of the tree).
+func (p *IncrementalProver) Prove(index uint64) (*Proof, error) {
+	if index >= uint64(len(p.leaves)) {
+		return nil, errors.New(ErrIncrementalIndexOutOfRange)
+	}
+	return proveAt(p.HashFunc, p.concatFunc, p.leaves, index, uint64(len(p.leaves)))
+}
+
+// proveAt reconstructs the inclusion proof path for the leaf at index within leaves[:size], by
+// folding levels bottom-up and promoting a lonely trailing node unchanged whenever a level has an
+// odd length (rather than duplicating it), matching both the incremental tree's frontier shape and
+// RFC 6962's MTH definition.
+func proveAt(hashFunc TypeHashFunc, concatFunc func([]byte, []byte) []byte, leaves [][]byte, index, size uint64) (*Proof, error) {
+	var (
+		path     uint32
+		siblings [][]byte
+		level    [][]byte
+		idx      = index
+	)
+	level = make([][]byte, size)
+	copy(level, leaves[:size])
+	for len(level) > 1 {
+		next := make([][]byte, (len(level)+1)/2)
+		for i := 0; i+1 < len(level); i += 2 {
+			combined, err := hashFunc(concatFunc(level[i], level[i+1]))
+			if err != nil {
+				return nil, err
+			}
+			next[i/2] = combined
+		}
+		if len(level)%2 == 1 {
+			next[len(next)-1] = level[len(level)-1]
+		}
+		if idx^1 < uint64(len(level)) {
+			if idx&1 == 1 {
+				siblings = append(siblings, level[idx-1])
+			} else {
+				path += 1 << uint(len(siblings))
+				siblings = append(siblings, level[idx+1])
+			}
+		}
+		level = next
+		idx >>= 1
+	}
+	return &Proof{Path: path, Siblings: siblings}, nil
+}
+
+// ConsistencyProof returns the minimal set of hashes proving that the tree at oldSize is a prefix
+// of the tree at newSize, i.e. that no leaf already committed to at oldSize has since been altered
+// or reordered. oldSize and newSize are both leaf counts observed via prior Observe calls.
+func (p *IncrementalProver) ConsistencyProof(oldSize, newSize uint64) ([][]byte, error) {
+	if oldSize == 0 || oldSize > newSize || newSize > uint64(len(p.leaves)) {
+		return nil, errors.New(ErrIncrementalConsistencyRange)
+	}
+	if oldSize == newSize {
+		return nil, nil
+	}
+	var proof [][]byte
+	err := subProof(p.HashFunc, p.concatFunc, oldSize, p.leaves[:newSize], true, &proof)
+	return proof, err
+}
+
+// VerifyIncrementalConsistencyProof verifies that oldRoot (the root of an IncrementalTree at
+// oldSize leaves) is a prefix of newRoot (the same tree at newSize leaves), against a proof
+// produced by IncrementalProver.ConsistencyProof. Unlike RFC 6962's VerifyConsistencyProof, it
+// hashes with config's own HashFunc/concatFunc instead of the RFC 6962 domain-separated ones, so
+// it matches whatever convention the IncrementalTree being verified was built with.
+func VerifyIncrementalConsistencyProof(oldRoot, newRoot []byte, oldSize, newSize uint64, proof [][]byte, config *Config) (bool, error) {
+	if oldSize == 0 || oldSize > newSize {
+		return false, errors.New(ErrIncrementalConsistencyRange)
+	}
+	if config == nil {
+		config = new(Config)
+	}
+	if config.HashFunc == nil {
+		config.HashFunc = DefaultHashFunc
+	}
+	if config.concatFunc == nil {
+		if config.SortSiblingPairs {
+			config.concatFunc = concatSortHash
+		} else {
+			config.concatFunc = concatHash
+		}
+	}
+	if oldSize == newSize {
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot), nil
+	}
+	pos := 0
+	oldHash, newHash, err := verifyConsistencyRecur(
+		config.HashFunc, config.concatFunc, oldSize, newSize, true, oldRoot, proof, &pos,
+	)
+	if err != nil {
+		return false, err
+	}
+	if pos != len(proof) {
+		return false, errors.New(ErrConsistencyProofMalformed)
+	}
+	return bytes.Equal(oldHash, oldRoot) && bytes.Equal(newHash, newRoot), nil
+}
+
+// subProof implements the RFC 6962 §2.1.2-style SUBPROOF recursion over an explicit leaf slice,
+// shared between the incremental tree's consistency proofs and the RFC 6962 compatibility mode.
+func subProof(hashFunc TypeHashFunc, concatFunc func([]byte, []byte) []byte, m uint64, leaves [][]byte, complete bool, proof *[][]byte) error {
+	n := uint64(len(leaves))
+	if m == n {
+		if !complete {
+			root, err := subTreeHash(hashFunc, concatFunc, leaves)
+			if err != nil {
+				return err
+			}
+			*proof = append(*proof, root)
+		}
+		return nil
+	}
+	k := splitPoint(n)
+	if m <= k {
+		if err := subProof(hashFunc, concatFunc, m, leaves[:k], complete, proof); err != nil {
+			return err
+		}
+		right, err := subTreeHash(hashFunc, concatFunc, leaves[k:])
+		if err != nil {
+			return err
+		}
+		*proof = append(*proof, right)
+	} else {
+		left, err := subTreeHash(hashFunc, concatFunc, leaves[:k])
+		if err != nil {
+			return err
+		}
+		if err = subProof(hashFunc, concatFunc, m-k, leaves[k:], false, proof); err != nil {
+			return err
+		}
+		*proof = append(*proof, left)
+	}
+	return nil
+}
+
+// splitPoint returns the largest power of two strictly smaller than n, the standard split used by
+// RFC 6962 to divide a tree of n leaves into a left subtree of that size and a right remainder.
+func splitPoint(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// subTreeHash computes the Merkle root of an arbitrary (possibly non-power-of-two) leaf slice
+// using the standard left-heavy split, matching RFC 6962's MTH definition.
+func subTreeHash(hashFunc TypeHashFunc, concatFunc func([]byte, []byte) []byte, leaves [][]byte) ([]byte, error) {
+	if len(leaves) == 1 {
+		return leaves[0], nil
+	}
+	k := splitPoint(uint64(len(leaves)))
+	left, err := subTreeHash(hashFunc, concatFunc, leaves[:k])
+	if err != nil {
+		return nil, err
+	}
+	right, err := subTreeHash(hashFunc, concatFunc, leaves[k:])
+	if err != nil {
+		return nil, err
+	}
+	return hashFunc(concatFunc(left, right))
+}
@@ -0,0 +1,156 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// mockDataBlock is a minimal DataBlock used across this package's tests.
+type mockDataBlock struct {
+	data []byte
+}
+
+func (b *mockDataBlock) Serialize() ([]byte, error) {
+	return b.data, nil
+}
+
+// testHashFunc is a deterministic TypeHashFunc used across this package's tests, so test
+// expectations do not depend on whatever DefaultHashFunc happens to be.
+func testHashFunc(data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+func genDataBlocks(n int) []DataBlock {
+	blocks := make([]DataBlock, n)
+	for i := 0; i < n; i++ {
+		blocks[i] = &mockDataBlock{data: []byte(fmt.Sprintf("block-%d", i))}
+	}
+	return blocks
+}
+
+func TestIncrementalTreeAppendProveVerify(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 7, 8, 9, 16, 17} {
+		blocks := genDataBlocks(n)
+		config := &Config{HashFunc: testHashFunc}
+		tree := NewIncremental(config)
+		prover := NewIncrementalProver(config)
+		for _, block := range blocks {
+			leaf, err := leafFromBlock(block, &tree.Config)
+			if err != nil {
+				t.Fatalf("n=%d: leafFromBlock: %v", n, err)
+			}
+			if _, err = tree.Append(block); err != nil {
+				t.Fatalf("n=%d: Append: %v", n, err)
+			}
+			prover.Observe(leaf, tree.Snapshot())
+		}
+		root, err := tree.Root()
+		if err != nil {
+			t.Fatalf("n=%d: Root: %v", n, err)
+		}
+		for i := 0; i < n; i++ {
+			proof, err := prover.Prove(uint64(i))
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Prove: %v", n, i, err)
+			}
+			ok, err := Verify(blocks[i], proof, root, config)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Verify: %v", n, i, err)
+			}
+			if !ok {
+				t.Errorf("n=%d idx=%d: proof did not verify", n, i)
+			}
+		}
+	}
+}
+
+func TestIncrementalTreeSnapshotRestore(t *testing.T) {
+	config := &Config{HashFunc: testHashFunc}
+	blocks := genDataBlocks(9)
+	full := NewIncremental(config)
+	for _, block := range blocks {
+		if _, err := full.Append(block); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	fullRoot, err := full.Root()
+	if err != nil {
+		t.Fatalf("Root: %v", err)
+	}
+
+	resumed := NewIncremental(config)
+	for _, block := range blocks[:5] {
+		if _, err := resumed.Append(block); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	state := resumed.Snapshot()
+	encoded, err := state.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	decoded, err := DeserializeFrontierState(encoded)
+	if err != nil {
+		t.Fatalf("DeserializeFrontierState: %v", err)
+	}
+
+	resumedFromBytes := NewIncremental(config)
+	resumedFromBytes.Restore(decoded)
+	for _, block := range blocks[5:] {
+		if _, err := resumedFromBytes.Append(block); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	resumedRoot, err := resumedFromBytes.Root()
+	if err != nil {
+		t.Fatalf("Root: %v", err)
+	}
+	if !bytes.Equal(fullRoot, resumedRoot) {
+		t.Errorf("root after snapshot/restore resume does not match continuous append")
+	}
+}
+
+func TestIncrementalTreeConsistencyProof(t *testing.T) {
+	config := &Config{HashFunc: testHashFunc}
+	blocks := genDataBlocks(10)
+	tree := NewIncremental(config)
+	prover := NewIncrementalProver(config)
+	// roots[i] is the tree's root immediately after the i-th leaf was appended (1-indexed size).
+	roots := make([][]byte, len(blocks)+1)
+	for i, block := range blocks {
+		leaf, err := leafFromBlock(block, &tree.Config)
+		if err != nil {
+			t.Fatalf("leafFromBlock: %v", err)
+		}
+		if _, err = tree.Append(block); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		prover.Observe(leaf, tree.Snapshot())
+		if roots[i+1], err = tree.Root(); err != nil {
+			t.Fatalf("Root: %v", err)
+		}
+	}
+
+	for oldSize := uint64(1); oldSize <= 10; oldSize++ {
+		for newSize := oldSize; newSize <= 10; newSize++ {
+			proof, err := prover.ConsistencyProof(oldSize, newSize)
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d): %v", oldSize, newSize, err)
+			}
+			if oldSize == newSize && len(proof) != 0 {
+				t.Errorf("ConsistencyProof(%d, %d): expected empty proof, got %d hashes", oldSize, newSize, len(proof))
+			}
+			oldRoot, newRoot := roots[oldSize], roots[newSize]
+			ok, err := VerifyIncrementalConsistencyProof(oldRoot, newRoot, oldSize, newSize, proof, config)
+			if err != nil {
+				t.Fatalf("VerifyIncrementalConsistencyProof(%d, %d): %v", oldSize, newSize, err)
+			}
+			if !ok {
+				t.Errorf("VerifyIncrementalConsistencyProof(%d, %d): consistency proof did not verify against real roots", oldSize, newSize)
+			}
+		}
+	}
+}
@@ -106,6 +106,15 @@ type Config struct {
 	SortSiblingPairs bool
 	// If true, the leaf nodes are NOT hashed before being added to the Merkle Tree.
 	DisableLeafHashing bool
+	// RFC6962 switches the tree to RFC 6962 (Certificate Transparency) compatible hashing:
+	// leaves are hashed as HashFunc(0x00 || data), internal nodes as HashFunc(0x01 || left || right),
+	// sibling pairs are never sorted, and a lone trailing node at an odd level is promoted to the
+	// next level unchanged instead of being duplicated. See ConsistencyProof and VerifyConsistencyProof.
+	RFC6962 bool
+	// Store, if set, backs the tree's internal node structure with a NodeStore instead of the
+	// default in-memory node array, so trees with more leaves than fit in RAM can be built and
+	// queried level-by-level against disk or a key/value database. See NodeStore and LoadTree.
+	Store NodeStore
 }
 
 // MerkleTree implements the Merkle Tree data structure.
@@ -165,7 +174,12 @@ func New(config *Config, blocks []DataBlock) (m *MerkleTree, err error) {
 	}
 	// Hash concatenation function initialization.
 	if m.concatFunc == nil {
-		if m.SortSiblingPairs {
+		if m.RFC6962 {
+			// RFC 6962 never sorts sibling pairs, and every internal node hash is prefixed with
+			// rfc6962NodePrefix, so the generic Verify/Proof path must use the same concatenation
+			// rfc6962Build/rfc6962Proof/ConsistencyProof hash internally.
+			m.concatFunc = rfc6962Concat
+		} else if m.SortSiblingPairs {
 			m.concatFunc = concatSortHash
 		} else {
 			m.concatFunc = concatHash
@@ -195,6 +209,18 @@ func New(config *Config, blocks []DataBlock) (m *MerkleTree, err error) {
 	if m.Mode == 0 {
 		m.Mode = ModeProofGen
 	}
+	// RFC 6962 trees use a dedicated, sequential construction path: the odd-fan-in promotion
+	// rule does not fit the parallel engine's fixed pairwise batching, and RFC 6962 trees are
+	// used for CT log auditing/monitoring rather than the high-throughput workloads the
+	// parallel engine targets.
+	if m.RFC6962 {
+		m.leafMap = make(map[string]int)
+		return m, m.rfc6962Build()
+	}
+	if m.Store != nil {
+		m.leafMap = make(map[string]int)
+		return m, m.storeBuild()
+	}
 	if m.Mode == ModeProofGen {
 		err = m.proofGen()
 		return
@@ -332,6 +358,8 @@ func proofGenHandler(arg poolWorkerArgs) error {
 // fixOdd fixes the odd-length slice by appending a node to it.
 // If NoDuplicates is true, append a node by duplicating the previous node.
 // Otherwise, append a node by random.
+// RFC6962 trees never call fixOdd: they are built by rfc6962Build, which promotes a lonely
+// trailing node to the next level unchanged instead of duplicating it.
 func (m *MerkleTree) fixOdd(buf [][]byte, prevLen int) ([][]byte, int) {
 	if prevLen&1 == 0 {
 		return buf, prevLen
@@ -437,6 +465,9 @@ func leafFromBlock(block DataBlock, config *Config) ([]byte, error) {
 		copy(leaf, blockBytes)
 		return leaf, nil
 	}
+	if config.RFC6962 {
+		return config.HashFunc(append([]byte{rfc6962LeafPrefix}, blockBytes...))
+	}
 	return config.HashFunc(blockBytes)
 }
 
@@ -636,6 +667,12 @@ func (m *MerkleTree) Proof(dataBlock DataBlock) (*Proof, error) {
 	if !ok {
 		return nil, errors.New(ErrProofInvalidDataBlock)
 	}
+	if m.RFC6962 {
+		return m.rfc6962Proof(idx)
+	}
+	if m.Store != nil {
+		return m.storeProof(idx)
+	}
 	var (
 		path     uint32
 		siblings = make([][]byte, m.Depth)
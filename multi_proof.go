@@ -0,0 +1,247 @@
+// MIT License
+//
+// Copyright (c) 2023 Tommy TIAN
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+const (
+	// ErrMultiProofInvalidIndices is the error message for an empty set of target data blocks
+	// passed to MultiProof.
+	ErrMultiProofInvalidIndices = "multi-proof requires at least one data block"
+	// ErrMultiProofLengthMismatch is the error message for a mismatch between the number of data
+	// blocks passed to VerifyMultiProof and the number of indices carried by the MultiProof.
+	ErrMultiProofLengthMismatch = "number of data blocks does not match the multi-proof's indices"
+	// ErrMultiProofMalformed is the error message for a multi-proof whose bitmap does not consume
+	// exactly the number of sibling hashes VerifyMultiProof expects while replaying it.
+	ErrMultiProofMalformed = "multi-proof bitmap and sibling stream are inconsistent"
+	// ErrMultiProofNodesUnavailable is the error message for a MultiProof request against a tree
+	// that was not built with a materialized node array (RFC6962 or Store-backed trees build their
+	// nodes through a dedicated path and never populate MerkleTree.nodes).
+	ErrMultiProofNodesUnavailable = "multi-proof requires a tree built without RFC6962 or a Store"
+)
+
+// MultiProof is a Merkle proof for a batch of leaves, carrying only the deduplicated set of
+// sibling hashes actually required to reconstruct the root from the given leaves, rather than a
+// fully independent Proof per leaf.
+type MultiProof struct {
+	// Indices holds the sorted, deduplicated leaf indices this proof covers.
+	Indices []int
+	// Siblings holds the sibling hashes that could not be derived from the proven leaves
+	// themselves, in the order they are consumed by the level-by-level reconstruction walk.
+	Siblings [][]byte
+	// Bitmap has one entry per sibling pair visited during reconstruction, in walk order: true
+	// means the pair's missing half was supplied from Siblings, false means both halves of the
+	// pair were already known and no data was required.
+	Bitmap []bool
+	// NumLeaves is the number of leaves in the Merkle Tree the proof was generated against.
+	NumLeaves int
+	// Depth is the depth of the Merkle Tree the proof was generated against.
+	Depth int
+}
+
+// MultiProof generates a batched inclusion proof for the given data blocks. The tree must have
+// been built with ModeTreeBuild or ModeProofGenAndTreeBuild.
+func (m *MerkleTree) MultiProof(blocks []DataBlock) (*MultiProof, error) {
+	if m.Mode != ModeTreeBuild && m.Mode != ModeProofGenAndTreeBuild {
+		return nil, errors.New(ErrProofInvalidModeTreeNotBuilt)
+	}
+	if len(blocks) == 0 {
+		return nil, errors.New(ErrMultiProofInvalidIndices)
+	}
+	if m.RFC6962 || m.Store != nil {
+		return nil, errors.New(ErrMultiProofNodesUnavailable)
+	}
+	seen := make(map[int]bool, len(blocks))
+	indices := make([]int, 0, len(blocks))
+	for _, block := range blocks {
+		if block == nil {
+			return nil, errors.New(ErrDataBlockIsNil)
+		}
+		leaf, err := leafFromBlock(block, &m.Config)
+		if err != nil {
+			return nil, err
+		}
+		m.leafMapMu.Lock()
+		idx, ok := m.leafMap[string(leaf)]
+		m.leafMapMu.Unlock()
+		if !ok {
+			return nil, errors.New(ErrProofInvalidDataBlock)
+		}
+		if seen[idx] {
+			continue
+		}
+		seen[idx] = true
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	known := make(map[int]bool, len(indices))
+	for _, idx := range indices {
+		known[idx] = true
+	}
+	var (
+		siblings [][]byte
+		bitmap   []bool
+	)
+	for level := 0; level < m.Depth; level++ {
+		next := make(map[int]bool)
+		processed := make(map[int]bool)
+		for _, idx := range sortedIntKeys(known) {
+			parent := idx >> 1
+			if processed[parent] {
+				continue
+			}
+			processed[parent] = true
+			pair := idx ^ 1
+			if known[pair] {
+				bitmap = append(bitmap, false)
+			} else {
+				siblings = append(siblings, m.nodes[level][pair])
+				bitmap = append(bitmap, true)
+			}
+			next[parent] = true
+		}
+		known = next
+	}
+	return &MultiProof{
+		Indices:   indices,
+		Siblings:  siblings,
+		Bitmap:    bitmap,
+		NumLeaves: m.NumLeaves,
+		Depth:     m.Depth,
+	}, nil
+}
+
+// VerifyMultiProof verifies a batch of data blocks against a MultiProof and a Merkle root hash.
+// blocks must be supplied in the same ascending index order as mp.Indices, i.e. blocks[i] is the
+// data block for leaf index mp.Indices[i].
+func VerifyMultiProof(blocks []DataBlock, mp *MultiProof, root []byte, config *Config) (bool, error) {
+	if mp == nil {
+		return false, errors.New(ErrProofIsNil)
+	}
+	if len(blocks) != len(mp.Indices) {
+		return false, errors.New(ErrMultiProofLengthMismatch)
+	}
+	if config == nil {
+		config = new(Config)
+	}
+	if config.HashFunc == nil {
+		config.HashFunc = DefaultHashFunc
+	}
+	if config.concatFunc == nil {
+		if config.SortSiblingPairs {
+			config.concatFunc = concatSortHash
+		} else {
+			config.concatFunc = concatHash
+		}
+	}
+
+	known := make(map[int][]byte, len(blocks))
+	for i, block := range blocks {
+		if block == nil {
+			return false, errors.New(ErrDataBlockIsNil)
+		}
+		leaf, err := leafFromBlock(block, config)
+		if err != nil {
+			return false, err
+		}
+		known[mp.Indices[i]] = leaf
+	}
+
+	var siblingPos, bitmapPos int
+	for level := 0; level < mp.Depth; level++ {
+		next := make(map[int][]byte)
+		processed := make(map[int]bool)
+		for _, idx := range sortedIntKeysWithHash(known) {
+			parent := idx >> 1
+			if processed[parent] {
+				continue
+			}
+			processed[parent] = true
+			pair := idx ^ 1
+			if bitmapPos >= len(mp.Bitmap) {
+				return false, errors.New(ErrMultiProofMalformed)
+			}
+			provided := mp.Bitmap[bitmapPos]
+			bitmapPos++
+			var pairHash []byte
+			if provided {
+				if siblingPos >= len(mp.Siblings) {
+					return false, errors.New(ErrMultiProofMalformed)
+				}
+				pairHash = mp.Siblings[siblingPos]
+				siblingPos++
+			} else {
+				h, ok := known[pair]
+				if !ok {
+					return false, errors.New(ErrMultiProofMalformed)
+				}
+				pairHash = h
+			}
+			var left, right []byte
+			if idx&1 == 1 {
+				left, right = pairHash, known[idx]
+			} else {
+				left, right = known[idx], pairHash
+			}
+			combined, err := config.HashFunc(config.concatFunc(left, right))
+			if err != nil {
+				return false, err
+			}
+			next[parent] = combined
+		}
+		known = next
+	}
+	if bitmapPos != len(mp.Bitmap) || siblingPos != len(mp.Siblings) {
+		return false, errors.New(ErrMultiProofMalformed)
+	}
+	result, ok := known[0]
+	if !ok || len(known) != 1 {
+		return false, nil
+	}
+	return bytes.Equal(result, root), nil
+}
+
+// sortedIntKeys returns the keys of a map[int]bool in ascending order.
+func sortedIntKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// sortedIntKeysWithHash returns the keys of a map[int][]byte in ascending order.
+func sortedIntKeysWithHash(m map[int][]byte) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
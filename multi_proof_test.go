@@ -0,0 +1,98 @@
+package merkletree
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestMultiProofRoundTrip(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 8, 9, 16, 17} {
+		for _, sort := range []bool{false, true} {
+			for _, disableLeafHashing := range []bool{false, true} {
+				blocks := genDataBlocks(n)
+				config := &Config{
+					HashFunc:           testHashFunc,
+					Mode:               ModeTreeBuild,
+					SortSiblingPairs:   sort,
+					DisableLeafHashing: disableLeafHashing,
+				}
+				tree, err := New(config, blocks)
+				if err != nil {
+					t.Fatalf("n=%d sort=%v disableLeafHashing=%v: New: %v", n, sort, disableLeafHashing, err)
+				}
+				uniqueIndices := sortedUniqueInts([]int{0, n / 2, n - 1})
+				targets := make([]DataBlock, len(uniqueIndices))
+				for i, idx := range uniqueIndices {
+					targets[i] = blocks[idx]
+				}
+				mp, err := tree.MultiProof(targets)
+				if err != nil {
+					t.Fatalf("n=%d sort=%v disableLeafHashing=%v: MultiProof: %v", n, sort, disableLeafHashing, err)
+				}
+				ok, err := VerifyMultiProof(targets, mp, tree.Root, config)
+				if err != nil {
+					t.Fatalf("n=%d sort=%v disableLeafHashing=%v: VerifyMultiProof: %v", n, sort, disableLeafHashing, err)
+				}
+				if !ok {
+					t.Errorf("n=%d sort=%v disableLeafHashing=%v: multi-proof did not verify", n, sort, disableLeafHashing)
+				}
+			}
+		}
+	}
+}
+
+func sortedUniqueInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	unique := make([]int, 0, len(values))
+	for _, v := range values {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		unique = append(unique, v)
+	}
+	sort.Ints(unique)
+	return unique
+}
+
+func TestMultiProofDeduplicatesIndices(t *testing.T) {
+	blocks := genDataBlocks(8)
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild}
+	tree, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	mp, err := tree.MultiProof([]DataBlock{blocks[1], blocks[1], blocks[3]})
+	if err != nil {
+		t.Fatalf("MultiProof: %v", err)
+	}
+	if len(mp.Indices) != 2 {
+		t.Fatalf("expected 2 deduplicated indices, got %d", len(mp.Indices))
+	}
+}
+
+func TestMultiProofNilDataBlock(t *testing.T) {
+	blocks := genDataBlocks(4)
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild}
+	tree, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = tree.MultiProof([]DataBlock{blocks[0], nil})
+	if err == nil || err.Error() != ErrDataBlockIsNil {
+		t.Errorf("expected ErrDataBlockIsNil, got %v", err)
+	}
+}
+
+func TestMultiProofNodesUnavailable(t *testing.T) {
+	blocks := genDataBlocks(4)
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, RFC6962: true}
+	tree, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = tree.MultiProof([]DataBlock{blocks[0]})
+	if err == nil || err.Error() != ErrMultiProofNodesUnavailable {
+		t.Errorf("expected ErrMultiProofNodesUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,430 @@
+// MIT License
+//
+// Copyright (c) 2023 Tommy TIAN
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package merkletree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// metaLevel is the reserved NodeStore level under which a built tree's metadata (NumLeaves, Depth,
+// Root) is stored, so LoadTree can reopen a persisted tree without re-hashing its leaves.
+const metaLevel = -1
+
+const (
+	// ErrNodeStoreMiss is the error message for a NodeStore lookup of a node that was never written.
+	ErrNodeStoreMiss = "node store has no entry at the requested level and index"
+	// ErrNodeStoreHashSizeMismatch is the error message for a FileStore level whose entries are not
+	// all the same length, which FileStore's fixed-stride layout requires.
+	ErrNodeStoreHashSizeMismatch = "node store level's hash size changed between writes"
+)
+
+// NodeStore abstracts the Merkle Tree's internal node array, so trees with more leaves than fit in
+// RAM can keep their nodes on disk or in an external key/value database instead.
+type NodeStore interface {
+	// Get returns the hash stored at (level, index), where level 0 holds the leaves.
+	Get(level, index int) ([]byte, error)
+	// Put stores hash at (level, index), overwriting any previous value there.
+	Put(level, index int, hash []byte) error
+	// Flush persists any buffered writes. A tree build calls Flush after each level completes, so
+	// implementations that cache recent writes may use this as their eviction point.
+	Flush() error
+	// Close releases any resources (file handles, connections) held by the store.
+	Close() error
+}
+
+// InMemoryStore is the default NodeStore, backed by an in-memory, per-level slice of hashes. It
+// reproduces the Merkle Tree's original, fully-materialized node array behind the NodeStore
+// interface.
+type InMemoryStore struct {
+	levels [][][]byte
+}
+
+// NewInMemoryStore creates an empty InMemoryStore.
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{}
+}
+
+// Get implements NodeStore.
+func (s *InMemoryStore) Get(level, index int) ([]byte, error) {
+	if level < 0 {
+		level = len(s.levels) - 1
+		if level < 0 || index >= len(s.levels[level]) {
+			return nil, errors.New(ErrNodeStoreMiss)
+		}
+		return s.levels[level][index], nil
+	}
+	if level >= len(s.levels) || index >= len(s.levels[level]) || s.levels[level][index] == nil {
+		return nil, errors.New(ErrNodeStoreMiss)
+	}
+	return s.levels[level][index], nil
+}
+
+// Put implements NodeStore.
+func (s *InMemoryStore) Put(level, index int, hash []byte) error {
+	if level < 0 {
+		level = len(s.levels)
+	}
+	for len(s.levels) <= level {
+		s.levels = append(s.levels, nil)
+	}
+	for len(s.levels[level]) <= index {
+		s.levels[level] = append(s.levels[level], nil)
+	}
+	s.levels[level][index] = hash
+	return nil
+}
+
+// Flush implements NodeStore. InMemoryStore has nothing to persist.
+func (s *InMemoryStore) Flush() error { return nil }
+
+// Close implements NodeStore. InMemoryStore holds no external resources.
+func (s *InMemoryStore) Close() error { return nil }
+
+// FileStore is a NodeStore backed by one file per level on disk, so a tree far larger than RAM can
+// be built and queried without holding every level in memory at once. Each level file is a flat,
+// fixed-stride array of hashes, addressed by index * hashSize, giving O(1) random access to any
+// node without scanning the file.
+// Each level's hash size is learned from the first Put to that level and kept only in memory, so
+// Get reads back only levels written through the same FileStore value: a FileStore newly created
+// over a directory that already holds level files returns ErrNodeStoreMiss for them, and LoadTree
+// cannot reopen a tree from such a store.
+type FileStore struct {
+	dir      string
+	files    map[int]*os.File
+	hashSize map[int]int
+}
+
+// NewFileStore creates a FileStore rooted at dir, which must already exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("node store directory %q is not accessible: %w", dir, err)
+	}
+	return &FileStore{
+		dir:      dir,
+		files:    make(map[int]*os.File),
+		hashSize: make(map[int]int),
+	}, nil
+}
+
+func (s *FileStore) fileFor(level int) (*os.File, error) {
+	if f, ok := s.files[level]; ok {
+		return f, nil
+	}
+	name := fmt.Sprintf("meta-%d.dat", -level)
+	if level >= 0 {
+		name = fmt.Sprintf("level-%d.dat", level)
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s.files[level] = f
+	return f, nil
+}
+
+// Get implements NodeStore.
+func (s *FileStore) Get(level, index int) ([]byte, error) {
+	f, err := s.fileFor(level)
+	if err != nil {
+		return nil, err
+	}
+	hashSize, ok := s.hashSize[level]
+	if !ok {
+		return nil, errors.New(ErrNodeStoreMiss)
+	}
+	hash := make([]byte, hashSize)
+	if _, err = f.ReadAt(hash, int64(index)*int64(hashSize)); err != nil {
+		return nil, errors.New(ErrNodeStoreMiss)
+	}
+	return hash, nil
+}
+
+// Put implements NodeStore.
+func (s *FileStore) Put(level, index int, hash []byte) error {
+	f, err := s.fileFor(level)
+	if err != nil {
+		return err
+	}
+	if hashSize, ok := s.hashSize[level]; ok {
+		if hashSize != len(hash) {
+			return errors.New(ErrNodeStoreHashSizeMismatch)
+		}
+	} else {
+		s.hashSize[level] = len(hash)
+	}
+	_, err = f.WriteAt(hash, int64(index)*int64(len(hash)))
+	return err
+}
+
+// Flush implements NodeStore, syncing every level file written so far.
+func (s *FileStore) Flush() error {
+	for _, f := range s.files {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements NodeStore, closing every level file.
+func (s *FileStore) Close() error {
+	for _, f := range s.files {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KVStore is the minimal interface a key/value backend (e.g. BoltDB, Badger, SQLite) must provide
+// to back a tree via NewKVNodeStore.
+type KVStore interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Close() error
+}
+
+// kvNodeStore adapts a KVStore to NodeStore by encoding (level, index) as a byte-sortable key.
+type kvNodeStore struct {
+	kv KVStore
+}
+
+// NewKVNodeStore wraps kv as a NodeStore, for users who already operate a BoltDB/Badger/SQLite (or
+// similar) key/value store and want the tree's nodes to live there instead of in a dedicated file
+// layout.
+func NewKVNodeStore(kv KVStore) NodeStore {
+	return &kvNodeStore{kv: kv}
+}
+
+func kvNodeKey(level, index int) []byte {
+	key := make([]byte, 13)
+	if level < 0 {
+		key[0] = 0xff // put negative (metadata) levels in their own key range, sorted after every level >= 0
+	}
+	binary.BigEndian.PutUint32(key[1:5], uint32(level))
+	binary.BigEndian.PutUint64(key[5:13], uint64(index))
+	return key
+}
+
+// Get implements NodeStore.
+func (s *kvNodeStore) Get(level, index int) ([]byte, error) {
+	value, err := s.kv.Get(kvNodeKey(level, index))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, errors.New(ErrNodeStoreMiss)
+	}
+	return value, nil
+}
+
+// Put implements NodeStore.
+func (s *kvNodeStore) Put(level, index int, hash []byte) error {
+	return s.kv.Put(kvNodeKey(level, index), hash)
+}
+
+// Flush implements NodeStore. Durability is delegated to the underlying KVStore.
+func (s *kvNodeStore) Flush() error { return nil }
+
+// Close implements NodeStore.
+func (s *kvNodeStore) Close() error { return s.kv.Close() }
+
+// storeBuild builds the tree level-by-level directly against m.Config.Store, so the full node
+// array never needs to fit in memory at once: each level is written and flushed (the store's
+// eviction point) before the next level is computed.
+func (m *MerkleTree) storeBuild() (err error) {
+	store := m.Config.Store
+	for i, leaf := range m.Leaves {
+		if err = store.Put(0, i, leaf); err != nil {
+			return err
+		}
+		m.leafMap[string(leaf)] = i
+	}
+	prevLen := m.NumLeaves
+	if err = m.storeFixOdd(store, 0, prevLen); err != nil {
+		return err
+	}
+	if prevLen&1 == 1 {
+		prevLen++
+	}
+	if err = store.Flush(); err != nil {
+		return err
+	}
+	for level := 0; level < m.Depth-1; level++ {
+		for j := 0; j < prevLen; j += 2 {
+			left, err := store.Get(level, j)
+			if err != nil {
+				return err
+			}
+			right, err := store.Get(level, j+1)
+			if err != nil {
+				return err
+			}
+			combined, err := m.HashFunc(m.concatFunc(left, right))
+			if err != nil {
+				return err
+			}
+			if err = store.Put(level+1, j>>1, combined); err != nil {
+				return err
+			}
+		}
+		nextLen := prevLen >> 1
+		if err = m.storeFixOdd(store, level+1, nextLen); err != nil {
+			return err
+		}
+		if nextLen&1 == 1 {
+			nextLen++
+		}
+		prevLen = nextLen
+		if err = store.Flush(); err != nil {
+			return err
+		}
+	}
+	left, err := store.Get(m.Depth-1, 0)
+	if err != nil {
+		return err
+	}
+	right, err := store.Get(m.Depth-1, 1)
+	if err != nil {
+		return err
+	}
+	if m.Root, err = m.HashFunc(m.concatFunc(left, right)); err != nil {
+		return err
+	}
+	header, err := encodeTreeHeader(m.NumLeaves, m.Depth, m.Root)
+	if err != nil {
+		return err
+	}
+	if err = store.Put(metaLevel, 0, header); err != nil {
+		return err
+	}
+	return store.Flush()
+}
+
+// storeFixOdd duplicates the last node of a level within store if length is odd, mirroring the
+// default (non-RFC6962) in-memory fixOdd behavior.
+func (m *MerkleTree) storeFixOdd(store NodeStore, level, length int) error {
+	if length&1 == 0 {
+		return nil
+	}
+	last, err := store.Get(level, length-1)
+	if err != nil {
+		return err
+	}
+	return store.Put(level, length, last)
+}
+
+// storeProof generates the inclusion proof for the leaf at idx by lazily fetching siblings through
+// m.Config.Store instead of the in-memory node array.
+func (m *MerkleTree) storeProof(idx int) (*Proof, error) {
+	store := m.Config.Store
+	var (
+		path     uint32
+		siblings = make([][]byte, m.Depth)
+		err      error
+	)
+	for i := 0; i < m.Depth; i++ {
+		if idx&1 == 1 {
+			siblings[i], err = store.Get(i, idx-1)
+		} else {
+			path += 1 << i
+			siblings[i], err = store.Get(i, idx+1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		idx >>= 1
+	}
+	return &Proof{Path: path, Siblings: siblings}, nil
+}
+
+// encodeTreeHeader serializes a built tree's metadata for storage at metaLevel.
+func encodeTreeHeader(numLeaves, depth int, root []byte) ([]byte, error) {
+	buf := make([]byte, 16+len(root))
+	binary.BigEndian.PutUint64(buf, uint64(numLeaves))
+	binary.BigEndian.PutUint64(buf[8:], uint64(depth))
+	copy(buf[16:], root)
+	return buf, nil
+}
+
+// decodeTreeHeader deserializes a header previously produced by encodeTreeHeader.
+func decodeTreeHeader(data []byte) (numLeaves, depth int, root []byte, err error) {
+	if len(data) < 16 {
+		return 0, 0, nil, errors.New(ErrNodeStoreMiss)
+	}
+	numLeaves = int(binary.BigEndian.Uint64(data))
+	depth = int(binary.BigEndian.Uint64(data[8:]))
+	root = data[16:]
+	return numLeaves, depth, root, nil
+}
+
+// LoadTree reopens a tree previously built with a NodeStore (via Config.Store), without
+// re-hashing the original data blocks: leaf hashes are read back from the store directly.
+func LoadTree(store NodeStore, config *Config) (*MerkleTree, error) {
+	if config == nil {
+		config = new(Config)
+	}
+	header, err := store.Get(metaLevel, 0)
+	if err != nil {
+		return nil, err
+	}
+	numLeaves, depth, root, err := decodeTreeHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	m := &MerkleTree{
+		Config:    *config,
+		NumLeaves: numLeaves,
+		Depth:     depth,
+		Root:      root,
+		leafMap:   make(map[string]int),
+		Leaves:    make([][]byte, numLeaves),
+	}
+	m.Config.Store = store
+	if m.HashFunc == nil {
+		m.HashFunc = DefaultHashFunc
+	}
+	if m.concatFunc == nil {
+		if m.SortSiblingPairs {
+			m.concatFunc = concatSortHash
+		} else {
+			m.concatFunc = concatHash
+		}
+	}
+	for i := 0; i < numLeaves; i++ {
+		leaf, err := store.Get(0, i)
+		if err != nil {
+			return nil, err
+		}
+		m.Leaves[i] = leaf
+		m.leafMap[string(leaf)] = i
+	}
+	if m.Mode == 0 {
+		m.Mode = ModeTreeBuild
+	}
+	return m, nil
+}
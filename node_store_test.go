@@ -0,0 +1,288 @@
+package merkletree
+
+import (
+	"bytes"
+	"testing"
+)
+
+// memKVStore is a minimal in-memory KVStore test double, used to exercise kvNodeStore without
+// depending on a real BoltDB/Badger/SQLite backend.
+type memKVStore struct {
+	data   map[string][]byte
+	closed bool
+}
+
+func newMemKVStore() *memKVStore {
+	return &memKVStore{data: make(map[string][]byte)}
+}
+
+func (s *memKVStore) Get(key []byte) ([]byte, error) {
+	return s.data[string(key)], nil
+}
+
+func (s *memKVStore) Put(key, value []byte) error {
+	s.data[string(key)] = value
+	return nil
+}
+
+func (s *memKVStore) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestNodeStoreBuildMatchesInMemoryTree(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 8, 9} {
+		blocks := genDataBlocks(n)
+		plainConfig := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild}
+		plainTree, err := New(plainConfig, blocks)
+		if err != nil {
+			t.Fatalf("n=%d: New (plain): %v", n, err)
+		}
+
+		storeConfig := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, Store: NewInMemoryStore()}
+		storeTree, err := New(storeConfig, blocks)
+		if err != nil {
+			t.Fatalf("n=%d: New (store): %v", n, err)
+		}
+		if !bytes.Equal(plainTree.Root, storeTree.Root) {
+			t.Errorf("n=%d: store-backed root does not match in-memory root", n)
+		}
+
+		for i, block := range blocks {
+			proof, err := storeTree.Proof(block)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Proof: %v", n, i, err)
+			}
+			ok, err := Verify(block, proof, storeTree.Root, storeConfig)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Verify: %v", n, i, err)
+			}
+			if !ok {
+				t.Errorf("n=%d idx=%d: store-backed proof did not verify", n, i)
+			}
+		}
+	}
+}
+
+func TestLoadTreeRoundTrip(t *testing.T) {
+	blocks := genDataBlocks(9)
+	store := NewInMemoryStore()
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, Store: store}
+	built, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	loaded, err := LoadTree(store, &Config{HashFunc: testHashFunc, Store: store})
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	if !bytes.Equal(built.Root, loaded.Root) {
+		t.Errorf("loaded tree root does not match originally built root")
+	}
+	if loaded.NumLeaves != built.NumLeaves {
+		t.Errorf("loaded tree has %d leaves, want %d", loaded.NumLeaves, built.NumLeaves)
+	}
+
+	proof, err := loaded.Proof(blocks[0])
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+	ok, err := Verify(blocks[0], proof, loaded.Root, config)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !ok {
+		t.Errorf("proof from loaded tree did not verify")
+	}
+}
+
+func TestFileStoreBuildMatchesInMemoryTree(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 8, 9} {
+		blocks := genDataBlocks(n)
+		plainConfig := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild}
+		plainTree, err := New(plainConfig, blocks)
+		if err != nil {
+			t.Fatalf("n=%d: New (plain): %v", n, err)
+		}
+
+		store, err := NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("n=%d: NewFileStore: %v", n, err)
+		}
+		defer store.Close()
+		storeConfig := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, Store: store}
+		storeTree, err := New(storeConfig, blocks)
+		if err != nil {
+			t.Fatalf("n=%d: New (store): %v", n, err)
+		}
+		if !bytes.Equal(plainTree.Root, storeTree.Root) {
+			t.Errorf("n=%d: FileStore-backed root does not match in-memory root", n)
+		}
+
+		for i, block := range blocks {
+			proof, err := storeTree.Proof(block)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Proof: %v", n, i, err)
+			}
+			ok, err := Verify(block, proof, storeTree.Root, storeConfig)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Verify: %v", n, i, err)
+			}
+			if !ok {
+				t.Errorf("n=%d idx=%d: FileStore-backed proof did not verify", n, i)
+			}
+		}
+	}
+}
+
+func TestFileStoreLoadTreeRoundTrip(t *testing.T) {
+	blocks := genDataBlocks(9)
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer store.Close()
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, Store: store}
+	built, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	loaded, err := LoadTree(store, &Config{HashFunc: testHashFunc, Store: store})
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	if !bytes.Equal(built.Root, loaded.Root) {
+		t.Errorf("loaded tree root does not match originally built root")
+	}
+	if loaded.NumLeaves != built.NumLeaves {
+		t.Errorf("loaded tree has %d leaves, want %d", loaded.NumLeaves, built.NumLeaves)
+	}
+
+	proof, err := loaded.Proof(blocks[0])
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+	ok, err := Verify(blocks[0], proof, loaded.Root, config)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !ok {
+		t.Errorf("proof from loaded tree did not verify")
+	}
+}
+
+func TestFileStoreGetPutOffsets(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer store.Close()
+
+	// Write several indices across two levels out of order, so a wrong stride would make one
+	// index's write clobber another's.
+	want := map[[2]int][]byte{
+		{0, 0}: []byte("aaaaaaaa"),
+		{0, 3}: []byte("dddddddd"),
+		{0, 1}: []byte("bbbbbbbb"),
+		{1, 0}: []byte("11111111"),
+		{1, 2}: []byte("33333333"),
+	}
+	for k, v := range want {
+		if err = store.Put(k[0], k[1], v); err != nil {
+			t.Fatalf("Put(%v): %v", k, err)
+		}
+	}
+	if err = store.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	for k, v := range want {
+		got, err := store.Get(k[0], k[1])
+		if err != nil {
+			t.Fatalf("Get(%v): %v", k, err)
+		}
+		if !bytes.Equal(got, v) {
+			t.Errorf("Get(%v) = %q, want %q", k, got, v)
+		}
+	}
+
+	if err = store.Put(0, 0, []byte("short")); err == nil {
+		t.Errorf("expected ErrNodeStoreHashSizeMismatch for a differently sized hash on an existing level")
+	}
+	if _, err = store.Get(0, 99); err == nil {
+		t.Errorf("expected an error reading an index that was never written")
+	}
+}
+
+func TestKVNodeStoreBuildMatchesInMemoryTree(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 8, 9} {
+		blocks := genDataBlocks(n)
+		plainConfig := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild}
+		plainTree, err := New(plainConfig, blocks)
+		if err != nil {
+			t.Fatalf("n=%d: New (plain): %v", n, err)
+		}
+
+		storeConfig := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, Store: NewKVNodeStore(newMemKVStore())}
+		storeTree, err := New(storeConfig, blocks)
+		if err != nil {
+			t.Fatalf("n=%d: New (store): %v", n, err)
+		}
+		if !bytes.Equal(plainTree.Root, storeTree.Root) {
+			t.Errorf("n=%d: KVStore-backed root does not match in-memory root", n)
+		}
+
+		for i, block := range blocks {
+			proof, err := storeTree.Proof(block)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Proof: %v", n, i, err)
+			}
+			ok, err := Verify(block, proof, storeTree.Root, storeConfig)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Verify: %v", n, i, err)
+			}
+			if !ok {
+				t.Errorf("n=%d idx=%d: KVStore-backed proof did not verify", n, i)
+			}
+		}
+	}
+}
+
+func TestKVNodeStoreLoadTreeRoundTrip(t *testing.T) {
+	blocks := genDataBlocks(9)
+	kv := newMemKVStore()
+	store := NewKVNodeStore(kv)
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, Store: store}
+	built, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	loaded, err := LoadTree(store, &Config{HashFunc: testHashFunc, Store: store})
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	if !bytes.Equal(built.Root, loaded.Root) {
+		t.Errorf("loaded tree root does not match originally built root")
+	}
+
+	proof, err := loaded.Proof(blocks[0])
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+	ok, err := Verify(blocks[0], proof, loaded.Root, config)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !ok {
+		t.Errorf("proof from loaded tree did not verify")
+	}
+
+	if err = store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !kv.closed {
+		t.Errorf("expected kvNodeStore.Close to close the underlying KVStore")
+	}
+}
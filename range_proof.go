@@ -0,0 +1,179 @@
+// MIT License
+//
+// Copyright (c) 2023 Tommy TIAN
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"math/bits"
+)
+
+const (
+	// ErrRangeProofInvalidRange is the error message for an invalid [startIdx, endIdx) range
+	// passed to RangeProof or VerifyRangeProof.
+	ErrRangeProofInvalidRange = "range proof requires 0 <= startIdx < endIdx <= number of leaves"
+	// ErrRangeProofLengthMismatch is the error message for a number of supplied leaves that does
+	// not match the width of the requested range.
+	ErrRangeProofLengthMismatch = "number of leaves does not match the requested range width"
+	// ErrRangeProofMalformed is the error message for a range proof whose sibling hashes do not
+	// match the range and tree size it claims to cover.
+	ErrRangeProofMalformed = "range proof siblings do not match the given range and number of leaves"
+	// ErrRangeProofNodesUnavailable is the error message for a RangeProof request against a tree
+	// that was not built with a materialized node array (RFC6962 or Store-backed trees build their
+	// nodes through a dedicated path and never populate MerkleTree.nodes).
+	ErrRangeProofNodesUnavailable = "range proof requires a tree built without RFC6962 or a Store"
+)
+
+// RangeProof is a Merkle proof authenticating the entire contiguous slice of leaves
+// [StartIdx, EndIdx) against the root, using only the frontier siblings outside the range instead
+// of an independent Proof per leaf.
+type RangeProof struct {
+	// StartIdx is the first leaf index covered by the proof, inclusive.
+	StartIdx int
+	// EndIdx is the last leaf index covered by the proof, exclusive.
+	EndIdx int
+	// LeftSiblings holds, level by level from the leaves upward, the sibling hash needed whenever
+	// the range's current left edge falls on the right side of a pair (i.e. its partner lies
+	// outside the range, to the left).
+	LeftSiblings [][]byte
+	// RightSiblings holds, level by level from the leaves upward, the sibling hash needed whenever
+	// the range's current right edge falls on the left side of a pair (i.e. its partner lies
+	// outside the range, to the right).
+	RightSiblings [][]byte
+	// NumLeaves is the number of leaves in the Merkle Tree the proof was generated against.
+	NumLeaves int
+	// Depth is the depth of the Merkle Tree the proof was generated against.
+	Depth int
+}
+
+// RangeProof generates a proof authenticating the contiguous leaves [startIdx, endIdx) as a whole.
+// The tree must have been built with ModeTreeBuild or ModeProofGenAndTreeBuild.
+func (m *MerkleTree) RangeProof(startIdx, endIdx int) (*RangeProof, error) {
+	if m.Mode != ModeTreeBuild && m.Mode != ModeProofGenAndTreeBuild {
+		return nil, errors.New(ErrProofInvalidModeTreeNotBuilt)
+	}
+	if m.RFC6962 || m.Store != nil {
+		return nil, errors.New(ErrRangeProofNodesUnavailable)
+	}
+	if startIdx < 0 || endIdx <= startIdx || endIdx > m.NumLeaves {
+		return nil, errors.New(ErrRangeProofInvalidRange)
+	}
+	var leftSiblings, rightSiblings [][]byte
+	lo, hi := startIdx, endIdx
+	for level := 0; level < m.Depth; level++ {
+		if lo&1 == 1 {
+			leftSiblings = append(leftSiblings, m.nodes[level][lo-1])
+		}
+		if hi&1 == 1 {
+			rightSiblings = append(rightSiblings, m.nodes[level][hi])
+		}
+		lo >>= 1
+		hi = (hi + 1) >> 1
+	}
+	return &RangeProof{
+		StartIdx:      startIdx,
+		EndIdx:        endIdx,
+		LeftSiblings:  leftSiblings,
+		RightSiblings: rightSiblings,
+		NumLeaves:     m.NumLeaves,
+		Depth:         m.Depth,
+	}, nil
+}
+
+// VerifyRangeProof verifies that leaves is exactly the ordered leaf hashes for [startIdx, endIdx)
+// in a tree of numLeaves leaves with the given root, per proof. leaves are already-hashed Merkle
+// Tree leaves (as found in MerkleTree.Leaves), not raw data blocks.
+func VerifyRangeProof(leaves [][]byte, startIdx, endIdx, numLeaves int, proof *RangeProof, root []byte, config *Config) (bool, error) {
+	if proof == nil {
+		return false, errors.New(ErrProofIsNil)
+	}
+	if startIdx < 0 || endIdx <= startIdx || endIdx > numLeaves {
+		return false, errors.New(ErrRangeProofInvalidRange)
+	}
+	if len(leaves) != endIdx-startIdx {
+		return false, errors.New(ErrRangeProofLengthMismatch)
+	}
+	if config == nil {
+		config = new(Config)
+	}
+	if config.HashFunc == nil {
+		config.HashFunc = DefaultHashFunc
+	}
+	if config.concatFunc == nil {
+		if config.SortSiblingPairs {
+			config.concatFunc = concatSortHash
+		} else {
+			config.concatFunc = concatHash
+		}
+	}
+
+	depth := bits.Len(uint(numLeaves - 1))
+	buf := make([][]byte, len(leaves))
+	copy(buf, leaves)
+	lo, hi := startIdx, endIdx
+	var leftPos, rightPos int
+	for level := 0; level < depth; level++ {
+		cur := buf
+		var next [][]byte
+		if lo&1 == 1 {
+			if leftPos >= len(proof.LeftSiblings) {
+				return false, errors.New(ErrRangeProofMalformed)
+			}
+			combined, err := config.HashFunc(config.concatFunc(proof.LeftSiblings[leftPos], cur[0]))
+			if err != nil {
+				return false, err
+			}
+			leftPos++
+			next = append(next, combined)
+			cur = cur[1:]
+		}
+		i := 0
+		for ; i+1 < len(cur); i += 2 {
+			combined, err := config.HashFunc(config.concatFunc(cur[i], cur[i+1]))
+			if err != nil {
+				return false, err
+			}
+			next = append(next, combined)
+		}
+		if hi&1 == 1 {
+			if i != len(cur)-1 || rightPos >= len(proof.RightSiblings) {
+				return false, errors.New(ErrRangeProofMalformed)
+			}
+			combined, err := config.HashFunc(config.concatFunc(cur[i], proof.RightSiblings[rightPos]))
+			if err != nil {
+				return false, err
+			}
+			rightPos++
+			next = append(next, combined)
+		} else if i != len(cur) {
+			return false, errors.New(ErrRangeProofMalformed)
+		}
+		buf = next
+		lo >>= 1
+		hi = (hi + 1) >> 1
+	}
+	if leftPos != len(proof.LeftSiblings) || rightPos != len(proof.RightSiblings) || len(buf) != 1 {
+		return false, errors.New(ErrRangeProofMalformed)
+	}
+	return bytes.Equal(buf[0], root), nil
+}
@@ -0,0 +1,76 @@
+package merkletree
+
+import "testing"
+
+func TestRangeProofRoundTrip(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 8, 9, 16, 17} {
+		for _, sort := range []bool{false, true} {
+			blocks := genDataBlocks(n)
+			config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, SortSiblingPairs: sort}
+			tree, err := New(config, blocks)
+			if err != nil {
+				t.Fatalf("n=%d sort=%v: New: %v", n, sort, err)
+			}
+			ranges := [][2]int{
+				{0, n},
+				{0, 1},
+				{n - 1, n},
+			}
+			if n >= 4 {
+				ranges = append(ranges, [2]int{1, n - 1}, [2]int{n / 2, n})
+			}
+			for _, r := range ranges {
+				start, end := r[0], r[1]
+				proof, err := tree.RangeProof(start, end)
+				if err != nil {
+					t.Fatalf("n=%d sort=%v range=[%d,%d): RangeProof: %v", n, sort, start, end, err)
+				}
+				leaves := tree.Leaves[start:end]
+				ok, err := VerifyRangeProof(leaves, start, end, n, proof, tree.Root, config)
+				if err != nil {
+					t.Fatalf("n=%d sort=%v range=[%d,%d): VerifyRangeProof: %v", n, sort, start, end, err)
+				}
+				if !ok {
+					t.Errorf("n=%d sort=%v range=[%d,%d): range proof did not verify", n, sort, start, end)
+				}
+			}
+		}
+	}
+}
+
+func TestRangeProofRejectsTamperedLeaf(t *testing.T) {
+	blocks := genDataBlocks(9)
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild}
+	tree, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	start, end := 2, 6
+	proof, err := tree.RangeProof(start, end)
+	if err != nil {
+		t.Fatalf("RangeProof: %v", err)
+	}
+	leaves := make([][]byte, end-start)
+	copy(leaves, tree.Leaves[start:end])
+	leaves[1] = tree.Leaves[start] // corrupt one leaf hash in the range
+	ok, err := VerifyRangeProof(leaves, start, end, len(blocks), proof, tree.Root, config)
+	if err != nil {
+		t.Fatalf("VerifyRangeProof: %v", err)
+	}
+	if ok {
+		t.Errorf("expected tampered range to fail verification")
+	}
+}
+
+func TestRangeProofNodesUnavailable(t *testing.T) {
+	blocks := genDataBlocks(4)
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, RFC6962: true}
+	tree, err := New(config, blocks)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = tree.RangeProof(0, 2)
+	if err == nil || err.Error() != ErrRangeProofNodesUnavailable {
+		t.Errorf("expected ErrRangeProofNodesUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+// MIT License
+//
+// Copyright (c) 2023 Tommy TIAN
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+)
+
+const (
+	// rfc6962LeafPrefix is prepended to leaf data before hashing, per RFC 6962 §2.1.
+	rfc6962LeafPrefix = 0x00
+	// rfc6962NodePrefix is prepended to a concatenated sibling pair before hashing, per RFC 6962 §2.1.
+	rfc6962NodePrefix = 0x01
+)
+
+const (
+	// ErrConsistencyProofInvalidRange is the error message for an invalid (oldSize, newSize) pair
+	// passed to ConsistencyProof or VerifyConsistencyProof.
+	ErrConsistencyProofInvalidRange = "old size must be greater than 0 and not exceed the tree's new size"
+	// ErrConsistencyProofMalformed is the error message for a consistency proof with leftover or
+	// insufficient hashes for the given (oldSize, newSize) pair.
+	ErrConsistencyProofMalformed = "consistency proof does not match the given tree sizes"
+)
+
+// rfc6962Concat concatenates a sibling pair with the RFC 6962 internal-node domain separator.
+// RFC 6962 never sorts sibling pairs, so this ignores SortSiblingPairs.
+func rfc6962Concat(left, right []byte) []byte {
+	result := make([]byte, 1+len(left)+len(right))
+	result[0] = rfc6962NodePrefix
+	copy(result[1:], left)
+	copy(result[1+len(left):], right)
+	return result
+}
+
+// rfc6962Build constructs an RFC 6962 compatible tree from m.Leaves. Rather than the parallel,
+// fixed-pairwise-batch engine used by treeBuild/proofGen, it computes MTH(D[n]) directly, which
+// naturally promotes a lonely trailing node unchanged instead of duplicating it.
+func (m *MerkleTree) rfc6962Build() (err error) {
+	for i, leaf := range m.Leaves {
+		m.leafMap[string(leaf)] = i
+	}
+	if m.Root, err = subTreeHash(m.HashFunc, rfc6962Concat, m.Leaves); err != nil {
+		return err
+	}
+	if m.Mode == ModeProofGen || m.Mode == ModeProofGenAndTreeBuild {
+		m.Proofs = make([]*Proof, m.NumLeaves)
+		for i := range m.Leaves {
+			if m.Proofs[i], err = m.rfc6962Proof(i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rfc6962Proof generates the inclusion proof for the leaf at idx.
+func (m *MerkleTree) rfc6962Proof(idx int) (*Proof, error) {
+	return proveAt(m.HashFunc, rfc6962Concat, m.Leaves, uint64(idx), uint64(m.NumLeaves))
+}
+
+// ConsistencyProof returns the minimal set of hashes proving that the tree at oldSize is a prefix
+// of the tree at newSize, per RFC 6962 §2.1.2. Both sizes are leaf counts not exceeding m.NumLeaves.
+func (m *MerkleTree) ConsistencyProof(oldSize, newSize int) ([][]byte, error) {
+	if oldSize <= 0 || oldSize > newSize || newSize > m.NumLeaves {
+		return nil, errors.New(ErrConsistencyProofInvalidRange)
+	}
+	if oldSize == newSize {
+		return nil, nil
+	}
+	var proof [][]byte
+	err := subProof(m.HashFunc, rfc6962Concat, uint64(oldSize), m.Leaves[:newSize], true, &proof)
+	return proof, err
+}
+
+// VerifyConsistencyProof verifies that oldRoot (a tree of oldSize leaves) is a prefix of newRoot (a
+// tree of newSize leaves), per the RFC 6962 §2.1.2 SUBPROOF recursion, replayed against the
+// provided proof hashes instead of real leaf data.
+func VerifyConsistencyProof(oldRoot, newRoot []byte, oldSize, newSize int, proof [][]byte, config *Config) (bool, error) {
+	if oldSize <= 0 || oldSize > newSize {
+		return false, errors.New(ErrConsistencyProofInvalidRange)
+	}
+	if config == nil {
+		config = new(Config)
+	}
+	if config.HashFunc == nil {
+		config.HashFunc = DefaultHashFunc
+	}
+	if oldSize == newSize {
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot), nil
+	}
+	pos := 0
+	oldHash, newHash, err := verifyConsistencyRecur(
+		config.HashFunc, rfc6962Concat, uint64(oldSize), uint64(newSize), true, oldRoot, proof, &pos,
+	)
+	if err != nil {
+		return false, err
+	}
+	if pos != len(proof) {
+		return false, errors.New(ErrConsistencyProofMalformed)
+	}
+	return bytes.Equal(oldHash, oldRoot) && bytes.Equal(newHash, newRoot), nil
+}
+
+// verifyConsistencyRecur mirrors the SUBPROOF(m, D[n], complete) recursion used to generate the
+// proof, consuming proof hashes from pos in the exact order subProof appended them, and returns
+// both the old tree's root-equivalent hash for this fragment and the new tree's MTH for it.
+// concatFunc is parameterized so the same recursion backs both RFC 6962's VerifyConsistencyProof
+// and IncrementalTree's VerifyIncrementalConsistencyProof, which use different concat conventions.
+func verifyConsistencyRecur(
+	hashFunc TypeHashFunc, concatFunc func([]byte, []byte) []byte, m, n uint64, complete bool, oldRoot []byte, proof [][]byte, pos *int,
+) (oldHash, newHash []byte, err error) {
+	if m == n {
+		if complete {
+			return oldRoot, oldRoot, nil
+		}
+		if *pos >= len(proof) {
+			return nil, nil, errors.New(ErrConsistencyProofMalformed)
+		}
+		v := proof[*pos]
+		*pos++
+		return v, v, nil
+	}
+	k := splitPoint(n)
+	if m <= k {
+		leftOld, leftNew, err := verifyConsistencyRecur(hashFunc, concatFunc, m, k, complete, oldRoot, proof, pos)
+		if err != nil {
+			return nil, nil, err
+		}
+		if *pos >= len(proof) {
+			return nil, nil, errors.New(ErrConsistencyProofMalformed)
+		}
+		rightNew := proof[*pos]
+		*pos++
+		newHash, err = hashFunc(concatFunc(leftNew, rightNew))
+		if err != nil {
+			return nil, nil, err
+		}
+		return leftOld, newHash, nil
+	}
+	rightOld, rightNew, err := verifyConsistencyRecur(hashFunc, concatFunc, m-k, n-k, false, oldRoot, proof, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	if *pos >= len(proof) {
+		return nil, nil, errors.New(ErrConsistencyProofMalformed)
+	}
+	leftNew := proof[*pos]
+	*pos++
+	if newHash, err = hashFunc(concatFunc(leftNew, rightNew)); err != nil {
+		return nil, nil, err
+	}
+	if oldHash, err = hashFunc(concatFunc(leftNew, rightOld)); err != nil {
+		return nil, nil, err
+	}
+	return oldHash, newHash, nil
+}
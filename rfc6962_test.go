@@ -0,0 +1,55 @@
+package merkletree
+
+import "testing"
+
+func TestRFC6962VerifyInclusion(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 8, 9, 16} {
+		blocks := genDataBlocks(n)
+		config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, RFC6962: true}
+		tree, err := New(config, blocks)
+		if err != nil {
+			t.Fatalf("n=%d: New: %v", n, err)
+		}
+		for i, block := range blocks {
+			proof, err := tree.Proof(block)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Proof: %v", n, i, err)
+			}
+			ok, err := tree.Verify(block, proof)
+			if err != nil {
+				t.Fatalf("n=%d idx=%d: Verify: %v", n, i, err)
+			}
+			if !ok {
+				t.Errorf("n=%d idx=%d: RFC6962 inclusion proof did not verify", n, i)
+			}
+		}
+	}
+}
+
+func TestRFC6962ConsistencyProof(t *testing.T) {
+	blocks := genDataBlocks(10)
+	config := &Config{HashFunc: testHashFunc, Mode: ModeTreeBuild, RFC6962: true}
+	for oldSize := 2; oldSize <= len(blocks); oldSize++ {
+		oldConfigTree, err := New(config, blocks[:oldSize])
+		if err != nil {
+			t.Fatalf("oldSize=%d: New: %v", oldSize, err)
+		}
+		for newSize := oldSize; newSize <= len(blocks); newSize++ {
+			newConfigTree, err := New(config, blocks[:newSize])
+			if err != nil {
+				t.Fatalf("oldSize=%d newSize=%d: New: %v", oldSize, newSize, err)
+			}
+			proof, err := newConfigTree.ConsistencyProof(oldSize, newSize)
+			if err != nil {
+				t.Fatalf("oldSize=%d newSize=%d: ConsistencyProof: %v", oldSize, newSize, err)
+			}
+			ok, err := VerifyConsistencyProof(oldConfigTree.Root, newConfigTree.Root, oldSize, newSize, proof, config)
+			if err != nil {
+				t.Fatalf("oldSize=%d newSize=%d: VerifyConsistencyProof: %v", oldSize, newSize, err)
+			}
+			if !ok {
+				t.Errorf("oldSize=%d newSize=%d: consistency proof did not verify", oldSize, newSize)
+			}
+		}
+	}
+}
@@ -0,0 +1,446 @@
+// MIT License
+//
+// Copyright (c) 2023 Tommy TIAN
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+)
+
+// sparseTreeDepth is the fixed depth of a SparseMerkleTree, i.e. the key length in bits. A
+// 256-bit key matches the output size of common cryptographic hash functions, so keys can be
+// derived by hashing arbitrary-length identifiers.
+const sparseTreeDepth = 256
+
+const (
+	// ErrSparseKeyWrongLength is the error message for a key whose length does not match
+	// sparseTreeDepth bits.
+	ErrSparseKeyWrongLength = "sparse merkle tree key must be 32 bytes (256 bits) long"
+	// ErrSparseValueIsNil is the error message for a nil value passed to Update.
+	ErrSparseValueIsNil = "sparse merkle tree value must not be nil, use Delete to remove a key"
+	// ErrSparseProofMalformed is the error message for a SparseProof whose bitmap and sibling
+	// stream are inconsistent with each other.
+	ErrSparseProofMalformed = "sparse proof bitmap and sibling stream are inconsistent"
+)
+
+// smtNode is a node of a SparseMerkleTree. A nil *smtNode represents an empty subtree. A node
+// with isLeaf set represents the highest point in the tree at which its subtree contains exactly
+// one populated key; it is never subdivided further, which keeps the materialized tree at O(N)
+// nodes instead of O(N*sparseTreeDepth).
+type smtNode struct {
+	left, right *smtNode
+	key         []byte
+	valueHash   []byte
+	isLeaf      bool
+}
+
+// SparseMerkleTree is a fixed-depth, key/value authenticated map. Unlike MerkleTree, which commits
+// to an append-once vector of data blocks, SparseMerkleTree commits to a sparse map keyed by a
+// 256-bit key, and supports both inclusion and non-membership proofs.
+type SparseMerkleTree struct {
+	Config
+	root *smtNode
+	// values holds the raw value bytes for every populated key, for O(1) Get.
+	values map[string][]byte
+	// defaultHash[i] is the root hash of a fully empty subtree i levels above the leaf level,
+	// i.e. defaultHash[0] = HashFunc(nil) and defaultHash[i] = HashFunc(defaultHash[i-1] || defaultHash[i-1]).
+	defaultHash [][]byte
+}
+
+// SparseProof is a Merkle proof of membership or non-membership in a SparseMerkleTree.
+type SparseProof struct {
+	// Siblings holds the non-default sibling hashes encountered walking from the root towards
+	// the proven key, top-down, omitting any sibling that is a default (empty subtree) hash.
+	Siblings [][]byte
+	// Bitmap has one entry per level walked (which may be fewer than sparseTreeDepth, since the
+	// walk stops as soon as it reaches an empty slot or a collapsed leaf): true means the
+	// sibling at that level is a real hash present in Siblings, false means it is the default
+	// hash for that level and was omitted.
+	Bitmap []bool
+	// TerminalKey is the full key of the leaf the walk terminated at, or nil if the walk
+	// terminated at an empty subtree.
+	TerminalKey []byte
+	// TerminalValueHash is the value hash of the leaf the walk terminated at, or nil if the walk
+	// terminated at an empty subtree.
+	TerminalValueHash []byte
+}
+
+// NewSparseMerkleTree creates a new, empty SparseMerkleTree with the specified configuration.
+func NewSparseMerkleTree(config *Config) (*SparseMerkleTree, error) {
+	if config == nil {
+		config = new(Config)
+	}
+	t := &SparseMerkleTree{
+		Config: *config,
+		values: make(map[string][]byte),
+	}
+	if t.HashFunc == nil {
+		t.HashFunc = DefaultHashFunc
+	}
+	if t.concatFunc == nil {
+		if t.SortSiblingPairs {
+			t.concatFunc = concatSortHash
+		} else {
+			t.concatFunc = concatHash
+		}
+	}
+	emptyLeaf, err := t.HashFunc(nil)
+	if err != nil {
+		return nil, err
+	}
+	t.defaultHash = make([][]byte, sparseTreeDepth+1)
+	t.defaultHash[0] = emptyLeaf
+	for i := 1; i <= sparseTreeDepth; i++ {
+		if t.defaultHash[i], err = t.HashFunc(t.concatFunc(t.defaultHash[i-1], t.defaultHash[i-1])); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// bitAt returns the bit of key at the given depth (0-indexed from the most significant bit).
+func bitAt(key []byte, depth int) int {
+	return int(key[depth/8]>>(7-uint(depth%8))) & 1
+}
+
+// Update inserts or overwrites the value stored at key.
+func (t *SparseMerkleTree) Update(key, value []byte) error {
+	if len(key) != sparseTreeDepth/8 {
+		return errors.New(ErrSparseKeyWrongLength)
+	}
+	if value == nil {
+		return errors.New(ErrSparseValueIsNil)
+	}
+	valueHash, err := t.HashFunc(value)
+	if err != nil {
+		return err
+	}
+	keyCopy := make([]byte, len(key))
+	copy(keyCopy, key)
+	root, err := t.insert(t.root, keyCopy, valueHash, 0)
+	if err != nil {
+		return err
+	}
+	t.root = root
+	t.values[string(key)] = value
+	return nil
+}
+
+// Delete removes key from the tree, if present. Deleting an absent key is a no-op.
+func (t *SparseMerkleTree) Delete(key []byte) error {
+	if len(key) != sparseTreeDepth/8 {
+		return errors.New(ErrSparseKeyWrongLength)
+	}
+	t.root = deleteLeaf(t.root, key, 0)
+	delete(t.values, string(key))
+	return nil
+}
+
+// Get returns the value stored at key, and whether key is present in the tree.
+func (t *SparseMerkleTree) Get(key []byte) ([]byte, bool) {
+	value, ok := t.values[string(key)]
+	return value, ok
+}
+
+// insert places (key, valueHash) into the subtree rooted at node, which starts at the given depth,
+// collapsing back down to a single leaf whenever a subtree contains only one key.
+func (t *SparseMerkleTree) insert(node *smtNode, key, valueHash []byte, depth int) (*smtNode, error) {
+	if node == nil {
+		return &smtNode{isLeaf: true, key: key, valueHash: valueHash}, nil
+	}
+	if node.isLeaf {
+		if bytes.Equal(node.key, key) {
+			return &smtNode{isLeaf: true, key: key, valueHash: valueHash}, nil
+		}
+		return t.split(node, key, valueHash, depth)
+	}
+	var err error
+	if bitAt(key, depth) == 0 {
+		if node.left, err = t.insert(node.left, key, valueHash, depth+1); err != nil {
+			return nil, err
+		}
+	} else {
+		if node.right, err = t.insert(node.right, key, valueHash, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// split replaces a lone leaf with an internal node once a second, distinct key lands in its
+// subtree, descending one level at a time until the two keys' bits diverge.
+func (t *SparseMerkleTree) split(oldLeaf *smtNode, newKey, newValueHash []byte, depth int) (*smtNode, error) {
+	oldBit := bitAt(oldLeaf.key, depth)
+	newBit := bitAt(newKey, depth)
+	node := new(smtNode)
+	if oldBit == newBit {
+		child, err := t.split(oldLeaf, newKey, newValueHash, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if oldBit == 0 {
+			node.left = child
+		} else {
+			node.right = child
+		}
+		return node, nil
+	}
+	newLeaf := &smtNode{isLeaf: true, key: newKey, valueHash: newValueHash}
+	if oldBit == 0 {
+		node.left, node.right = oldLeaf, newLeaf
+	} else {
+		node.left, node.right = newLeaf, oldLeaf
+	}
+	return node, nil
+}
+
+// deleteLeaf removes key from the subtree rooted at node, re-collapsing any internal node left
+// with only one populated child back into a lone leaf.
+func deleteLeaf(node *smtNode, key []byte, depth int) *smtNode {
+	if node == nil {
+		return nil
+	}
+	if node.isLeaf {
+		if bytes.Equal(node.key, key) {
+			return nil
+		}
+		return node
+	}
+	if bitAt(key, depth) == 0 {
+		node.left = deleteLeaf(node.left, key, depth+1)
+	} else {
+		node.right = deleteLeaf(node.right, key, depth+1)
+	}
+	switch {
+	case node.left == nil && node.right == nil:
+		return nil
+	case node.left == nil && node.right.isLeaf:
+		return node.right
+	case node.right == nil && node.left.isLeaf:
+		return node.left
+	default:
+		return node
+	}
+}
+
+// hashAt computes the root hash of the subtree rooted at node, which starts at the given depth.
+func (t *SparseMerkleTree) hashAt(node *smtNode, depth int) ([]byte, error) {
+	if node == nil {
+		return t.defaultHash[sparseTreeDepth-depth], nil
+	}
+	if node.isLeaf {
+		return t.foldLeaf(node.key, node.valueHash, depth)
+	}
+	left, err := t.hashAt(node.left, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.hashAt(node.right, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return t.HashFunc(t.concatFunc(left, right))
+}
+
+// foldLeaf folds a lone leaf's value hash up from the true leaf level to the given depth, using
+// the default hash as the sibling at every level in between (since the leaf is the only key in
+// its entire subtree, every sibling it meets on the way up is an empty subtree).
+func (t *SparseMerkleTree) foldLeaf(key, valueHash []byte, depth int) ([]byte, error) {
+	acc := valueHash
+	var err error
+	for lvl := sparseTreeDepth - 1; lvl >= depth; lvl-- {
+		def := t.defaultHash[sparseTreeDepth-lvl-1]
+		if bitAt(key, lvl) == 0 {
+			acc, err = t.HashFunc(t.concatFunc(acc, def))
+		} else {
+			acc, err = t.HashFunc(t.concatFunc(def, acc))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// Root returns the current root hash of the tree.
+func (t *SparseMerkleTree) Root() ([]byte, error) {
+	return t.hashAt(t.root, 0)
+}
+
+// Prove generates a SparseProof for key, which may be used to prove either membership (if key is
+// present) or non-membership (if key is absent).
+func (t *SparseMerkleTree) Prove(key []byte) (*SparseProof, error) {
+	if len(key) != sparseTreeDepth/8 {
+		return nil, errors.New(ErrSparseKeyWrongLength)
+	}
+	var (
+		siblings [][]byte
+		bitmap   []bool
+		node     = t.root
+		depth    = 0
+	)
+	for depth < sparseTreeDepth && node != nil && !node.isLeaf {
+		var sibling *smtNode
+		if bitAt(key, depth) == 0 {
+			sibling, node = node.right, node.left
+		} else {
+			sibling, node = node.left, node.right
+		}
+		sibHash, err := t.hashAt(sibling, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(sibHash, t.defaultHash[sparseTreeDepth-depth-1]) {
+			bitmap = append(bitmap, false)
+		} else {
+			bitmap = append(bitmap, true)
+			siblings = append(siblings, sibHash)
+		}
+		depth++
+	}
+	proof := &SparseProof{Siblings: siblings, Bitmap: bitmap}
+	if node != nil {
+		proof.TerminalKey = node.key
+		proof.TerminalValueHash = node.valueHash
+	}
+	return proof, nil
+}
+
+// VerifySparse verifies a SparseProof against a tree root, a key, and a claimed value. A nil value
+// verifies non-membership: that key is absent from the tree committed to by root.
+func VerifySparse(root, key, value []byte, proof *SparseProof, config *Config) (bool, error) {
+	if proof == nil {
+		return false, errors.New(ErrProofIsNil)
+	}
+	if len(key) != sparseTreeDepth/8 {
+		return false, errors.New(ErrSparseKeyWrongLength)
+	}
+	if len(proof.Bitmap) > sparseTreeDepth {
+		return false, errors.New(ErrSparseProofMalformed)
+	}
+	if proof.TerminalKey != nil && len(proof.TerminalKey) != sparseTreeDepth/8 {
+		return false, errors.New(ErrSparseProofMalformed)
+	}
+	if config == nil {
+		config = new(Config)
+	}
+	if config.HashFunc == nil {
+		config.HashFunc = DefaultHashFunc
+	}
+	if config.concatFunc == nil {
+		if config.SortSiblingPairs {
+			config.concatFunc = concatSortHash
+		} else {
+			config.concatFunc = concatHash
+		}
+	}
+	depth := len(proof.Bitmap)
+	isMember := bytes.Equal(proof.TerminalKey, key) && proof.TerminalKey != nil
+	if value != nil && !isMember {
+		return false, nil
+	}
+	if value == nil && isMember {
+		return false, nil
+	}
+
+	defaultHash, err := sparseDefaultHashes(config, sparseTreeDepth)
+	if err != nil {
+		return false, err
+	}
+
+	var acc []byte
+	if proof.TerminalKey == nil {
+		acc = defaultHash[sparseTreeDepth-depth]
+	} else {
+		// The walk followed key's bits down to depth, so the leaf it ended at shares that prefix.
+		for lvl := 0; lvl < depth; lvl++ {
+			if bitAt(proof.TerminalKey, lvl) != bitAt(key, lvl) {
+				return false, nil
+			}
+		}
+		if isMember {
+			valueHash, err := config.HashFunc(value)
+			if err != nil {
+				return false, err
+			}
+			if !bytes.Equal(valueHash, proof.TerminalValueHash) {
+				return false, nil
+			}
+			acc = valueHash
+		} else {
+			acc = proof.TerminalValueHash
+		}
+		for lvl := sparseTreeDepth - 1; lvl >= depth; lvl-- {
+			def := defaultHash[sparseTreeDepth-lvl-1]
+			if bitAt(proof.TerminalKey, lvl) == 0 {
+				acc, err = config.HashFunc(config.concatFunc(acc, def))
+			} else {
+				acc, err = config.HashFunc(config.concatFunc(def, acc))
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	siblingPos := len(proof.Siblings)
+	for lvl := depth - 1; lvl >= 0; lvl-- {
+		var sib []byte
+		if proof.Bitmap[lvl] {
+			siblingPos--
+			if siblingPos < 0 {
+				return false, errors.New(ErrSparseProofMalformed)
+			}
+			sib = proof.Siblings[siblingPos]
+		} else {
+			sib = defaultHash[sparseTreeDepth-lvl-1]
+		}
+		if bitAt(key, lvl) == 0 {
+			acc, err = config.HashFunc(config.concatFunc(acc, sib))
+		} else {
+			acc, err = config.HashFunc(config.concatFunc(sib, acc))
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return bytes.Equal(acc, root), nil
+}
+
+// sparseDefaultHashes rebuilds the vector of default subtree hashes for the given configuration,
+// mirroring SparseMerkleTree's precomputed defaultHash so that verification does not require an
+// existing tree instance.
+func sparseDefaultHashes(config *Config, depth int) ([][]byte, error) {
+	defaultHash := make([][]byte, depth+1)
+	emptyLeaf, err := config.HashFunc(nil)
+	if err != nil {
+		return nil, err
+	}
+	defaultHash[0] = emptyLeaf
+	for i := 1; i <= depth; i++ {
+		if defaultHash[i], err = config.HashFunc(config.concatFunc(defaultHash[i-1], defaultHash[i-1])); err != nil {
+			return nil, err
+		}
+	}
+	return defaultHash, nil
+}
@@ -0,0 +1,118 @@
+package merkletree
+
+import "testing"
+
+func sparseKey(b byte) []byte {
+	key := make([]byte, sparseTreeDepth/8)
+	key[0] = b
+	return key
+}
+
+func TestSparseMerkleTreeMembership(t *testing.T) {
+	for _, sort := range []bool{false, true} {
+		config := &Config{HashFunc: testHashFunc, SortSiblingPairs: sort}
+		tree, err := NewSparseMerkleTree(config)
+		if err != nil {
+			t.Fatalf("sort=%v: NewSparseMerkleTree: %v", sort, err)
+		}
+		keyAlice, keyBob := sparseKey(0x01), sparseKey(0xF0)
+		if err = tree.Update(keyAlice, []byte("val-alice")); err != nil {
+			t.Fatalf("sort=%v: Update: %v", sort, err)
+		}
+		if err = tree.Update(keyBob, []byte("val-bob")); err != nil {
+			t.Fatalf("sort=%v: Update: %v", sort, err)
+		}
+		root, err := tree.Root()
+		if err != nil {
+			t.Fatalf("sort=%v: Root: %v", sort, err)
+		}
+		proof, err := tree.Prove(keyAlice)
+		if err != nil {
+			t.Fatalf("sort=%v: Prove: %v", sort, err)
+		}
+		ok, err := VerifySparse(root, keyAlice, []byte("val-alice"), proof, config)
+		if err != nil {
+			t.Fatalf("sort=%v: VerifySparse: %v", sort, err)
+		}
+		if !ok {
+			t.Errorf("sort=%v: expected membership proof to verify", sort)
+		}
+		ok, err = VerifySparse(root, keyAlice, []byte("wrong"), proof, config)
+		if err != nil {
+			t.Fatalf("sort=%v: VerifySparse: %v", sort, err)
+		}
+		if ok {
+			t.Errorf("sort=%v: expected proof for wrong value to fail verification", sort)
+		}
+	}
+}
+
+func TestSparseMerkleTreeNonMembership(t *testing.T) {
+	config := &Config{HashFunc: testHashFunc}
+	tree, err := NewSparseMerkleTree(config)
+	if err != nil {
+		t.Fatalf("NewSparseMerkleTree: %v", err)
+	}
+	present, absent := sparseKey(0x01), sparseKey(0x02)
+	if err = tree.Update(present, []byte("val")); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatalf("Root: %v", err)
+	}
+	proof, err := tree.Prove(absent)
+	if err != nil {
+		t.Fatalf("Prove: %v", err)
+	}
+	ok, err := VerifySparse(root, absent, nil, proof, config)
+	if err != nil {
+		t.Fatalf("VerifySparse: %v", err)
+	}
+	if !ok {
+		t.Errorf("expected non-membership proof to verify")
+	}
+}
+
+func TestVerifySparseRejectsShortTerminalKey(t *testing.T) {
+	config := &Config{HashFunc: testHashFunc}
+	key := sparseKey(0x01)
+	proof := &SparseProof{TerminalKey: []byte{0x02}, TerminalValueHash: []byte("val")}
+	ok, err := VerifySparse(make([]byte, 32), key, []byte("val"), proof, config)
+	if err == nil || err.Error() != ErrSparseProofMalformed {
+		t.Fatalf("expected ErrSparseProofMalformed, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestSparseMerkleTreeDelete(t *testing.T) {
+	config := &Config{HashFunc: testHashFunc}
+	tree, err := NewSparseMerkleTree(config)
+	if err != nil {
+		t.Fatalf("NewSparseMerkleTree: %v", err)
+	}
+	key := sparseKey(0x01)
+	if err = tree.Update(key, []byte("val")); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err = tree.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := tree.Get(key); ok {
+		t.Errorf("expected key to be absent after Delete")
+	}
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatalf("Root: %v", err)
+	}
+	proof, err := tree.Prove(key)
+	if err != nil {
+		t.Fatalf("Prove: %v", err)
+	}
+	ok, err := VerifySparse(root, key, nil, proof, config)
+	if err != nil {
+		t.Fatalf("VerifySparse: %v", err)
+	}
+	if !ok {
+		t.Errorf("expected non-membership proof to verify after delete")
+	}
+}